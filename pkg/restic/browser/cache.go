@@ -0,0 +1,85 @@
+/*
+Copyright The Stash Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package browser
+
+import (
+	"container/list"
+	"encoding/json"
+	"sync"
+)
+
+// defaultTreeCacheSize bounds how many snapshots' "restic ls --json" output
+// is kept in memory at once. Tree listings can be large, so this trades a
+// little memory for avoiding a restic invocation on every request for a
+// snapshot a user is actively browsing.
+const defaultTreeCacheSize = 32
+
+// treeCache is a fixed-size, least-recently-used cache of snapshot ID to
+// its decoded "restic ls --json" tree listing.
+type treeCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+type treeCacheEntry struct {
+	key  string
+	tree []json.RawMessage
+}
+
+func newTreeCache(capacity int) *treeCache {
+	return &treeCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+func (c *treeCache) get(id string) ([]json.RawMessage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[id]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*treeCacheEntry).tree, true
+}
+
+func (c *treeCache) add(id string, tree []json.RawMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[id]; ok {
+		elem.Value.(*treeCacheEntry).tree = tree
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	elem := c.order.PushFront(&treeCacheEntry{key: id, tree: tree})
+	c.entries[id] = elem
+
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		if oldest != nil {
+			c.order.Remove(oldest)
+			delete(c.entries, oldest.Value.(*treeCacheEntry).key)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+/*
+Copyright The Stash Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package browser
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTreeCacheGetMiss(t *testing.T) {
+	c := newTreeCache(2)
+	if _, ok := c.get("missing"); ok {
+		t.Fatal("expected miss on empty cache")
+	}
+}
+
+func TestTreeCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newTreeCache(2)
+	tree := func(s string) []json.RawMessage { return []json.RawMessage{json.RawMessage(s)} }
+
+	c.add("a", tree(`"a"`))
+	c.add("b", tree(`"b"`))
+
+	// touch "a" so it becomes more recently used than "b"
+	if _, ok := c.get("a"); !ok {
+		t.Fatal("expected hit for \"a\"")
+	}
+
+	c.add("c", tree(`"c"`))
+
+	if _, ok := c.get("b"); ok {
+		t.Fatal("expected \"b\" to be evicted as the least recently used entry")
+	}
+	if _, ok := c.get("a"); !ok {
+		t.Fatal("expected \"a\" to survive eviction")
+	}
+	if _, ok := c.get("c"); !ok {
+		t.Fatal("expected \"c\" to be present")
+	}
+}
@@ -0,0 +1,255 @@
+/*
+Copyright The Stash Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package browser exposes the snapshots referenced by a restic.BackupOutput
+// through a small read-only HTTP API, so a single file can be restored from
+// a Kubernetes-backed snapshot without running "restic mount".
+package browser
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+
+	"stash.appscode.dev/apimachinery/pkg/restic"
+)
+
+// Config identifies the restic repository and binary the Server shells out
+// to in order to answer browsing requests.
+type Config struct {
+	// ResticBinary is the path to the restic executable.
+	ResticBinary string
+	// RepoURL is passed to restic as RESTIC_REPOSITORY.
+	RepoURL string
+	// PasswordFile is passed to restic as RESTIC_PASSWORD_FILE.
+	PasswordFile string
+	// Env holds additional environment variables restic needs to reach the
+	// backend, e.g. cloud provider credentials.
+	Env []string
+}
+
+// env returns the full environment restic should run with: the current
+// process's own environment (restic needs HOME for its local cache dir,
+// plus whatever PATH/proxy vars it was started with), followed by any extra
+// vars from c.Env and then the repository credentials, so the credentials
+// take precedence over a duplicate key in c.Env.
+func (c Config) env() []string {
+	env := append(os.Environ(), c.Env...)
+	env = append(env,
+		"RESTIC_REPOSITORY="+c.RepoURL,
+		"RESTIC_PASSWORD_FILE="+c.PasswordFile,
+	)
+	return env
+}
+
+// Server exposes the snapshots referenced by a restic.BackupOutput over
+// HTTP: listing snapshots, listing a snapshot's tree, streaming a single
+// file out of a snapshot, and reporting per-snapshot stats.
+type Server struct {
+	cfg    Config
+	output *restic.BackupOutput
+
+	treeCache *treeCache
+}
+
+// NewServer returns a Server that answers browsing requests for the
+// snapshots recorded in output, against the repository described by cfg.
+func NewServer(cfg Config, output *restic.BackupOutput) *Server {
+	return &Server{
+		cfg:       cfg,
+		output:    output,
+		treeCache: newTreeCache(defaultTreeCacheSize),
+	}
+}
+
+// Routes returns the Server's HTTP handler.
+func (s *Server) Routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/snapshots", s.handleListSnapshots)
+	mux.HandleFunc("/snapshots/", s.handleSnapshotRoute)
+	return mux
+}
+
+// snapshotRef is the subset of an api_v1beta1.HostBackupStats entry and its
+// SnapshotStats a client needs to pick a snapshot to browse.
+type snapshotRef struct {
+	Host string `json:"host"`
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+func (s *Server) handleListSnapshots(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var refs []snapshotRef
+	for _, hb := range s.output.HostBackupStats {
+		for _, ss := range hb.SnapshotStats {
+			refs = append(refs, snapshotRef{Host: hb.Hostname, Name: ss.Name, Path: ss.Path})
+		}
+	}
+	writeJSON(w, refs)
+}
+
+// handleSnapshotRoute dispatches GET /snapshots/{id}/tree,
+// GET /snapshots/{id}/files/*path and GET /snapshots/{id}/stats.
+func (s *Server) handleSnapshotRoute(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rest := strings.TrimPrefix(r.URL.Path, "/snapshots/")
+	id, sub, ok := strings.Cut(rest, "/")
+	if !ok || id == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if !s.knownSnapshot(id) {
+		http.Error(w, "unknown snapshot id", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case sub == "tree":
+		s.handleTree(w, r, id)
+	case sub == "stats":
+		s.handleStats(w, r, id)
+	case strings.HasPrefix(sub, "files/"):
+		s.handleFile(w, r, id, strings.TrimPrefix(sub, "files/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// knownSnapshot reports whether id was produced by a backup this
+// BackupOutput recorded, so the browser only ever shells out to restic for
+// snapshots Stash itself created.
+func (s *Server) knownSnapshot(id string) bool {
+	for _, hb := range s.output.HostBackupStats {
+		for _, ss := range hb.SnapshotStats {
+			if ss.Name == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *Server) handleTree(w http.ResponseWriter, r *http.Request, id string) {
+	if tree, ok := s.treeCache.get(id); ok {
+		writeJSON(w, tree)
+		return
+	}
+
+	out, err := s.restic(r, "ls", "--json", id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	var tree []json.RawMessage
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if line == "" {
+			continue
+		}
+		tree = append(tree, json.RawMessage(line))
+	}
+	s.treeCache.add(id, tree)
+	writeJSON(w, tree)
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request, id string) {
+	out, err := s.restic(r, "stats", "--json", id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(out)
+}
+
+// handleFile streams a single file out of a snapshot via "restic dump". It
+// starts the process and only commits the 200 response once restic has
+// actually begun producing output, but restic can still fail partway
+// through a large file; by then the status code is already on the wire and
+// http.Error is a no-op. In that case the connection is hijacked and
+// closed abnormally rather than cleanly, so the client sees a transfer
+// error instead of a truncated file that looks like a complete one.
+func (s *Server) handleFile(w http.ResponseWriter, r *http.Request, id, path string) {
+	if path == "" {
+		http.Error(w, "missing file path", http.StatusBadRequest)
+		return
+	}
+
+	cmd := exec.CommandContext(r.Context(), s.cfg.ResticBinary, "dump", id, "/"+path)
+	cmd.Env = s.cfg.env()
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("restic dump failed: %v", err), http.StatusBadGateway)
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		http.Error(w, fmt.Sprintf("restic dump failed: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+
+	_, copyErr := io.Copy(w, stdout)
+	waitErr := cmd.Wait()
+	if copyErr != nil || waitErr != nil {
+		abortConnection(w)
+	}
+}
+
+// abortConnection closes the underlying connection abnormally so the
+// client's in-flight read fails instead of appearing to complete
+// successfully. It is only useful once a response's headers (and possibly
+// part of its body) have already been written, which is why this isn't
+// just an http.Error call.
+func abortConnection(w http.ResponseWriter) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return
+	}
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	_ = conn.Close()
+}
+
+// restic runs restic with the given args against s.cfg's repository and
+// returns its stdout.
+func (s *Server) restic(r *http.Request, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(r.Context(), s.cfg.ResticBinary, args...)
+	cmd.Env = s.cfg.env()
+	return cmd.Output()
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,141 @@
+/*
+Copyright The Stash Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restic
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// IntegrityReport is the structured result of "restic check", including the
+// per-pack and per-blob detail restic only prints when it was run with
+// --read-data or --read-data-subset=N%.
+type IntegrityReport struct {
+	// Verified is true only if restic reported no errors at all.
+	Verified bool `json:"verified"`
+	// DataReadPercent is the percentage of repository data restic actually
+	// read and verified, parsed from its "read data from pack..." progress
+	// lines. It is 0 when check was run without --read-data(-subset).
+	DataReadPercent float64 `json:"dataReadPercent,omitempty"`
+	// CorruptedPacks holds the IDs of packs restic found to contain
+	// unexpected data.
+	CorruptedPacks []string `json:"corruptedPacks,omitempty"`
+	// MissingBlobs holds the IDs of blobs referenced by the index/snapshots
+	// but absent from the repository.
+	MissingBlobs []string `json:"missingBlobs,omitempty"`
+	// Errors holds every error line restic printed, verbatim, for cases the
+	// structured fields above don't cover.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// extractCheckInfo parses the output of "restic check" (optionally run with
+// --read-data or --read-data-subset=N%) into a structured IntegrityReport.
+// Earlier versions of this function only looked for the literal
+// "no errors were found" line and threw everything else away.
+func extractCheckInfo(out []byte) *IntegrityReport {
+	report := &IntegrityReport{Verified: true}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || line == "no errors were found":
+			// nothing to record
+		case strings.HasPrefix(line, "read data from pack") && strings.Contains(line, "%"):
+			report.DataReadPercent = parseCheckPercent(line)
+		case strings.HasPrefix(line, "pack ") && strings.Contains(line, "contains unexpected data"):
+			report.Verified = false
+			report.Errors = append(report.Errors, line)
+			if id, ok := parsePackID(line); ok {
+				report.CorruptedPacks = append(report.CorruptedPacks, id)
+			}
+		case strings.HasPrefix(line, "missing blob"):
+			report.Verified = false
+			report.Errors = append(report.Errors, line)
+			if id, ok := parseBlobID(line); ok {
+				report.MissingBlobs = append(report.MissingBlobs, id)
+			}
+		case strings.Contains(line, "Load(") && strings.Contains(line, "returned error"):
+			report.Verified = false
+			report.Errors = append(report.Errors, line)
+		default:
+			// Any other line is recorded as an error only if it looks like
+			// one; restic's remaining informational lines are ignored.
+			if looksLikeCheckError(line) {
+				report.Verified = false
+				report.Errors = append(report.Errors, line)
+			}
+		}
+	}
+
+	return report
+}
+
+// looksLikeCheckError reports whether line is a restic check error message
+// rather than one of its informational progress/summary lines.
+func looksLikeCheckError(line string) bool {
+	return strings.Contains(line, "error:") || strings.HasPrefix(line, "error ")
+}
+
+// parseCheckPercent extracts the percentage from a line such as
+// "read data from pack 1234... (42.00%)".
+func parseCheckPercent(line string) float64 {
+	start := strings.LastIndex(line, "(")
+	end := strings.LastIndex(line, "%")
+	if start == -1 || end == -1 || end < start {
+		return 0
+	}
+	pct, err := strconv.ParseFloat(strings.TrimSpace(line[start+1:end]), 64)
+	if err != nil {
+		return 0
+	}
+	return pct
+}
+
+// parsePackID pulls the pack ID out of a line such as
+// "pack 1234abcd contains unexpected data".
+func parsePackID(line string) (string, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return "", false
+	}
+	return fields[1], true
+}
+
+// parseBlobID pulls the blob ID out of a line such as
+// "missing blob 1234abcd".
+func parseBlobID(line string) (string, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return "", false
+	}
+	return fields[2], true
+}
+
+// readDataArg returns the "restic check" flag that verifies the requested
+// percentage of repository data. percent >= 100 requests a full
+// --read-data pass; anything less requests the matching --read-data-subset.
+// Used by Restic.CheckWithReadData.
+func readDataArg(percent int) string {
+	if percent >= 100 {
+		return "--read-data"
+	}
+	return fmt.Sprintf("--read-data-subset=%d%%", percent)
+}
@@ -0,0 +1,78 @@
+/*
+Copyright The Stash Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restic
+
+import "testing"
+
+func TestExtractCheckInfoNoErrors(t *testing.T) {
+	report := extractCheckInfo([]byte("no errors were found\n"))
+	if !report.Verified {
+		t.Fatalf("expected Verified=true, got %+v", report)
+	}
+	if len(report.Errors) != 0 {
+		t.Fatalf("expected no errors, got %v", report.Errors)
+	}
+}
+
+func TestExtractCheckInfoReadDataSubset(t *testing.T) {
+	out := "read data from pack 1234abcd (42.50%)\n" +
+		"read data from pack 5678efgh (100.00%)\n" +
+		"no errors were found\n"
+
+	report := extractCheckInfo([]byte(out))
+	if !report.Verified {
+		t.Fatalf("expected Verified=true, got %+v", report)
+	}
+	if report.DataReadPercent != 100.00 {
+		t.Fatalf("expected DataReadPercent to reflect the last progress line (100.00), got %v", report.DataReadPercent)
+	}
+}
+
+func TestExtractCheckInfoCorruptedPackAndMissingBlob(t *testing.T) {
+	out := "pack 1234abcd contains unexpected data\n" +
+		"missing blob 5678efgh\n"
+
+	report := extractCheckInfo([]byte(out))
+	if report.Verified {
+		t.Fatal("expected Verified=false when errors are present")
+	}
+	if len(report.CorruptedPacks) != 1 || report.CorruptedPacks[0] != "1234abcd" {
+		t.Fatalf("expected CorruptedPacks=[1234abcd], got %v", report.CorruptedPacks)
+	}
+	if len(report.MissingBlobs) != 1 || report.MissingBlobs[0] != "5678efgh" {
+		t.Fatalf("expected MissingBlobs=[5678efgh], got %v", report.MissingBlobs)
+	}
+	if len(report.Errors) != 2 {
+		t.Fatalf("expected both lines recorded verbatim in Errors, got %v", report.Errors)
+	}
+}
+
+func TestReadDataArg(t *testing.T) {
+	cases := []struct {
+		percent int
+		want    string
+	}{
+		{percent: 100, want: "--read-data"},
+		{percent: 150, want: "--read-data"},
+		{percent: 10, want: "--read-data-subset=10%"},
+	}
+	for _, c := range cases {
+		if got := readDataArg(c.percent); got != c.want {
+			t.Errorf("readDataArg(%d) = %q, want %q", c.percent, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Copyright The Stash Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restic
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Snapshot is a restic snapshot as reported by "restic forget --json".
+type Snapshot struct {
+	ID       string    `json:"id"`
+	ShortID  string    `json:"short_id"`
+	Time     time.Time `json:"time"`
+	Hostname string    `json:"hostname"`
+	Paths    []string  `json:"paths"`
+	Tags     []string  `json:"tags,omitempty"`
+}
+
+// KeepReason pairs a kept snapshot with the retention policies that matched
+// it, e.g. "keep-daily", "keep-weekly", or a tag match.
+type KeepReason struct {
+	Snapshot Snapshot `json:"snapshot"`
+	Matches  []string `json:"matches"`
+}
+
+// ForgetGroup is one element of "restic forget --json"'s top-level array:
+// the keep/remove decision restic made for every snapshot sharing the same
+// host, paths and tags.
+type ForgetGroup struct {
+	Tags    []string     `json:"tags"`
+	Host    string       `json:"host"`
+	Paths   []string     `json:"paths"`
+	Keep    []Snapshot   `json:"keep"`
+	Remove  []Snapshot   `json:"remove"`
+	Reasons []KeepReason `json:"reasons"`
+}
+
+// GroupKey identifies the host/paths/tags a CleanupReport group applies to.
+type GroupKey struct {
+	Hostname string   `json:"hostname,omitempty"`
+	Paths    []string `json:"paths,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+// RemovedSnapshot is the minimal detail about a snapshot "restic forget"
+// removed, reported so users can see exactly what retention dropped.
+type RemovedSnapshot struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+}
+
+// GroupCleanupReport summarizes the retention decisions restic made for one
+// group of snapshots (same host/paths/tags).
+type GroupCleanupReport struct {
+	GroupKey GroupKey `json:"groupKey"`
+	// KeptByPolicy counts, per retention policy that matched (e.g.
+	// "keep-daily", "keep-weekly", a tag), how many snapshots it caused to
+	// be kept. A snapshot matching more than one policy is counted under
+	// each policy that matched it.
+	KeptByPolicy map[string]int64 `json:"keptByPolicy,omitempty"`
+	// Removed lists the snapshots this group's retention policy dropped.
+	Removed []RemovedSnapshot `json:"removed,omitempty"`
+}
+
+// CleanupReport is the structured result of "restic forget --json". Unlike
+// the aggregate keep/remove counts this package used to report, it breaks
+// retention decisions down per group and records which policy kept each
+// snapshot, so a misconfigured retention policy is actually debuggable.
+type CleanupReport struct {
+	Groups  []GroupCleanupReport `json:"groups,omitempty"`
+	Keep    int64                `json:"keep"`
+	Removed int64                `json:"removed"`
+}
+
+// extractCleanupInfo parses the output of "restic forget --json" into a
+// CleanupReport.
+func extractCleanupInfo(out []byte) (*CleanupReport, error) {
+	var groups []ForgetGroup
+	if err := json.Unmarshal(out, &groups); err != nil {
+		return nil, err
+	}
+
+	report := &CleanupReport{}
+	for _, g := range groups {
+		groupReport := GroupCleanupReport{
+			GroupKey: GroupKey{Hostname: g.Host, Paths: g.Paths, Tags: g.Tags},
+		}
+
+		for _, reason := range g.Reasons {
+			for _, match := range reason.Matches {
+				if groupReport.KeptByPolicy == nil {
+					groupReport.KeptByPolicy = make(map[string]int64)
+				}
+				groupReport.KeptByPolicy[match]++
+			}
+		}
+
+		for _, snap := range g.Remove {
+			groupReport.Removed = append(groupReport.Removed, RemovedSnapshot{ID: snap.ID, Time: snap.Time})
+		}
+
+		report.Groups = append(report.Groups, groupReport)
+		report.Keep += int64(len(g.Keep))
+		report.Removed += int64(len(g.Remove))
+	}
+
+	return report, nil
+}
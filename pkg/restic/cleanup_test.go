@@ -0,0 +1,75 @@
+/*
+Copyright The Stash Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restic
+
+import "testing"
+
+func TestExtractCleanupInfo(t *testing.T) {
+	out := `[
+		{
+			"tags": ["app=my-app"],
+			"host": "my-host",
+			"paths": ["/data"],
+			"keep": [
+				{"id": "aaa", "short_id": "aaa", "hostname": "my-host", "paths": ["/data"], "time": "2023-01-01T00:00:00Z"},
+				{"id": "bbb", "short_id": "bbb", "hostname": "my-host", "paths": ["/data"], "time": "2023-01-02T00:00:00Z"}
+			],
+			"remove": [
+				{"id": "ccc", "short_id": "ccc", "hostname": "my-host", "paths": ["/data"], "time": "2022-12-01T00:00:00Z"}
+			],
+			"reasons": [
+				{"snapshot": {"id": "aaa", "short_id": "aaa", "hostname": "my-host", "paths": ["/data"], "time": "2023-01-01T00:00:00Z"}, "matches": ["keep-daily", "keep-weekly"]},
+				{"snapshot": {"id": "bbb", "short_id": "bbb", "hostname": "my-host", "paths": ["/data"], "time": "2023-01-02T00:00:00Z"}, "matches": ["keep-daily"]}
+			]
+		}
+	]`
+
+	report, err := extractCleanupInfo([]byte(out))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if report.Keep != 2 {
+		t.Fatalf("expected Keep=2, got %d", report.Keep)
+	}
+	if report.Removed != 1 {
+		t.Fatalf("expected Removed=1, got %d", report.Removed)
+	}
+	if len(report.Groups) != 1 {
+		t.Fatalf("expected 1 group, got %d", len(report.Groups))
+	}
+
+	group := report.Groups[0]
+	if group.GroupKey.Hostname != "my-host" {
+		t.Fatalf("expected GroupKey.Hostname=my-host, got %q", group.GroupKey.Hostname)
+	}
+	if group.KeptByPolicy["keep-daily"] != 2 {
+		t.Fatalf("expected keep-daily to match both kept snapshots, got %d", group.KeptByPolicy["keep-daily"])
+	}
+	if group.KeptByPolicy["keep-weekly"] != 1 {
+		t.Fatalf("expected keep-weekly to match one kept snapshot, got %d", group.KeptByPolicy["keep-weekly"])
+	}
+	if len(group.Removed) != 1 || group.Removed[0].ID != "ccc" {
+		t.Fatalf("expected Removed=[ccc], got %v", group.Removed)
+	}
+}
+
+func TestExtractCleanupInfoInvalidJSON(t *testing.T) {
+	if _, err := extractCleanupInfo([]byte("not json")); err == nil {
+		t.Fatal("expected an error for invalid JSON input")
+	}
+}
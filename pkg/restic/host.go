@@ -0,0 +1,41 @@
+/*
+Copyright The Stash Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restic
+
+import "os"
+
+// resticHostEnv is the environment variable newer restic releases read to
+// stamp a stable host identity onto snapshots (and to filter by, via
+// SnapshotFilter) instead of always using os.Hostname().
+const resticHostEnv = "RESTIC_HOST"
+
+// ResolveHost returns the host identity that should be passed to restic via
+// --host/RESTIC_HOST and recorded on HostBackupStats entries. explicit
+// takes precedence when set (e.g. a Stash CRD field); otherwise RESTIC_HOST
+// is honored; only when neither is set does it fall back to the pod's own
+// os.Hostname(). This lets a multi-pod StatefulSet backup give each pod a
+// stable, user-chosen identity that doesn't change if the pod is
+// rescheduled under a different name.
+func ResolveHost(explicit string) (string, error) {
+	if explicit != "" {
+		return explicit, nil
+	}
+	if host := os.Getenv(resticHostEnv); host != "" {
+		return host, nil
+	}
+	return os.Hostname()
+}
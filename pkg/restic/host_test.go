@@ -0,0 +1,63 @@
+/*
+Copyright The Stash Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restic
+
+import (
+	"os"
+	"testing"
+)
+
+func TestResolveHostPrefersExplicit(t *testing.T) {
+	t.Setenv(resticHostEnv, "env-host")
+
+	host, err := ResolveHost("explicit-host")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if host != "explicit-host" {
+		t.Fatalf("expected explicit host to win, got %q", host)
+	}
+}
+
+func TestResolveHostFallsBackToEnv(t *testing.T) {
+	t.Setenv(resticHostEnv, "env-host")
+
+	host, err := ResolveHost("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if host != "env-host" {
+		t.Fatalf("expected RESTIC_HOST to be used, got %q", host)
+	}
+}
+
+func TestResolveHostFallsBackToOSHostname(t *testing.T) {
+	os.Unsetenv(resticHostEnv)
+
+	want, err := os.Hostname()
+	if err != nil {
+		t.Skipf("os.Hostname unavailable in this environment: %v", err)
+	}
+
+	host, err := ResolveHost("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if host != want {
+		t.Fatalf("expected os.Hostname() fallback %q, got %q", want, host)
+	}
+}
@@ -17,14 +17,13 @@ limitations under the License.
 package restic
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/json"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"strings"
+	"time"
 
 	api_v1beta1 "stash.appscode.dev/stash/apis/stash/v1beta1"
 )
@@ -34,6 +33,11 @@ type BackupOutput struct {
 	HostBackupStats []api_v1beta1.HostBackupStats `json:"hostBackupStats,omitempty"`
 	// RepositoryStats shows statistics of repository after last backup
 	RepositoryStats RepositoryStats `json:"repository,omitempty"`
+
+	// ProgressCallback, when set, is invoked for every StatusEvent emitted
+	// by "restic backup --json" so callers can publish percent-complete
+	// and ETA to a status subresource before the backup finishes.
+	ProgressCallback func(StatusEvent) `json:"-"`
 }
 
 type RepositoryStats struct {
@@ -45,6 +49,15 @@ type RepositoryStats struct {
 	SnapshotCount int64 `json:"snapshotCount,omitempty"`
 	// SnapshotsRemovedOnLastCleanup shows number of old snapshots cleaned up according to retention policy on last backup session
 	SnapshotsRemovedOnLastCleanup int64 `json:"snapshotsRemovedOnLastCleanup,omitempty"`
+	// CheckReport gives structured detail about the last integrity check,
+	// including any corrupted packs or missing blobs found when restic was
+	// run with --read-data/--read-data-subset. It is nil when no check has
+	// run yet.
+	CheckReport *IntegrityReport `json:"checkReport,omitempty"`
+	// CleanupReport gives a per-group breakdown of the last "restic forget"
+	// run, including which retention policy kept each snapshot and the IDs
+	// of the snapshots it removed.
+	CleanupReport *CleanupReport `json:"cleanupReport,omitempty"`
 }
 
 type RestoreOutput struct {
@@ -112,77 +125,80 @@ func ReadRestoreOutput(filename string) (*RestoreOutput, error) {
 	return restoreOutput, nil
 }
 
-// ExtractBackupInfo extract information from output of "restic backup" command and
-// save valuable information into backupOutput
-func extractBackupInfo(output []byte, path string) (api_v1beta1.SnapshotStats, error) {
+// extractBackupInfo streams the output of "restic backup" command, forwarding
+// every StatusEvent to out.ProgressCallback (if set) as it arrives, and
+// returns the SnapshotStats decoded from the final summary message.
+//
+// NOTE: restic also stamps a "hostname" field onto the summary message and
+// emits ErrorEvent/VerboseStatusEvent lines along the way. HostBackupStats
+// doesn't yet have a Progress field to carry StatusEvent snapshots through
+// to the CRD status subresource; that needs to land in
+// stash.appscode.dev/stash/apis/stash/v1beta1 before the callback here can
+// be surfaced any further than this package. The same is true of
+// SnapshotStats.UploadedPacked/StartTime/EndTime/DryRun: BackupSummary now
+// decodes those values, but api_v1beta1.SnapshotStats has no matching
+// fields, so they are not reported; only the post-compression byte count
+// reaches users, through the Uploaded figure below, which prefers
+// data_added_packed when restic emits it.
+//
+// host is the explicit host identity resolved by ResolveHost (from
+// RESTIC_HOST or a Stash CRD field); extractBackupInfo returns the
+// effective host restic actually used so the caller can stamp it onto the
+// matching HostBackupStats entry instead of always using os.Hostname().
+func (out *BackupOutput) extractBackupInfo(output []byte, path, host string) (api_v1beta1.SnapshotStats, string, error) {
 	snapshotStats := api_v1beta1.SnapshotStats{
 		Path: path,
 	}
 
-	// unmarshal json output
-	var jsonOutput BackupSummary
-	dec := json.NewDecoder(bytes.NewReader(output))
+	var summary SummaryEvent
+	parser := NewBackupStreamParser(bytes.NewReader(output))
 	for {
-
-		err := dec.Decode(&jsonOutput)
+		event, err := parser.Next()
 		if err == io.EOF {
-			// all done
 			break
 		}
 		if err != nil {
-			return snapshotStats, err
+			return snapshotStats, host, err
 		}
-		// if message type is summary then we have found our desired message block
-		if jsonOutput.MessageType == "summary" {
-			break
+
+		switch e := event.(type) {
+		case StatusEvent:
+			if out.ProgressCallback != nil {
+				out.ProgressCallback(e)
+			}
+		case SummaryEvent:
+			summary = e
 		}
 	}
 
+	jsonOutput := summary.BackupSummary
+
 	snapshotStats.FileStats.NewFiles = jsonOutput.FilesNew
 	snapshotStats.FileStats.ModifiedFiles = jsonOutput.FilesChanged
 	snapshotStats.FileStats.UnmodifiedFiles = jsonOutput.FilesUnmodified
 	snapshotStats.FileStats.TotalFiles = jsonOutput.TotalFilesProcessed
 
-	snapshotStats.Uploaded = formatBytes(jsonOutput.DataAdded)
+	// Prefer the post-compression figure restic 0.17+ reports; fall back to
+	// the pre-compression one on older versions that don't emit it, rather
+	// than reporting zero bytes uploaded.
+	dataAdded := jsonOutput.DataAddedPacked
+	if dataAdded == 0 {
+		dataAdded = jsonOutput.DataAdded
+	}
+	snapshotStats.Uploaded = formatBytes(dataAdded)
 	snapshotStats.TotalSize = formatBytes(jsonOutput.TotalBytesProcessed)
 	snapshotStats.ProcessingTime = formatSeconds(uint64(jsonOutput.TotalDuration))
 	snapshotStats.Name = jsonOutput.SnapshotID
 
-	return snapshotStats, nil
-}
-
-// ExtractCheckInfo extract information from output of "restic check" command and
-// save valuable information into backupOutput
-func extractCheckInfo(out []byte) bool {
-	scanner := bufio.NewScanner(bytes.NewReader(out))
-	var line string
-	for scanner.Scan() {
-		line = scanner.Text()
-		line = strings.TrimSpace(line)
-		if line == "no errors were found" {
-			return true
-		}
-	}
-	return false
-}
-
-// ExtractCleanupInfo extract information from output of "restic forget" command and
-// save valuable information into backupOutput
-func extractCleanupInfo(out []byte) (int64, int64, error) {
-	var fg []ForgetGroup
-	err := json.Unmarshal(out, &fg)
-	if err != nil {
-		return 0, 0, err
-	}
-
-	var keep int64
-	var removed int64
-	for i := 0; i < len(fg); i++ {
-		keep += int64(len(fg[i].Keep))
-		removed += int64(len(fg[i].Remove))
+	// restic stamps the host it actually used onto the summary message; if
+	// the caller didn't pass one in explicitly, fall back to that rather
+	// than the pod's own os.Hostname(), which may not match what restic
+	// used if RESTIC_HOST was set in its environment.
+	if host == "" {
+		host = jsonOutput.Hostname
 	}
 
-	return keep, removed, nil
+	return snapshotStats, host, nil
 }
 
 // ExtractStatsInfo extract information from output of "restic stats" command and
@@ -197,20 +213,33 @@ func extractStatsInfo(out []byte) (string, error) {
 }
 
 type BackupSummary struct {
-	MessageType         string  `json:"message_type"` // "summary"
-	FilesNew            *int64  `json:"files_new"`
-	FilesChanged        *int64  `json:"files_changed"`
-	FilesUnmodified     *int64  `json:"files_unmodified"`
-	DataAdded           uint64  `json:"data_added"`
+	MessageType     string `json:"message_type"` // "summary"
+	FilesNew        *int64 `json:"files_new"`
+	FilesChanged    *int64 `json:"files_changed"`
+	FilesUnmodified *int64 `json:"files_unmodified"`
+	DataAdded       uint64 `json:"data_added"`
+	// DataAddedPacked is the post-compression bytes actually written to the
+	// repository. restic only started emitting this field in 0.17; it is
+	// zero on older versions, in which case callers should fall back to
+	// DataAdded.
+	DataAddedPacked     uint64  `json:"data_added_packed"`
 	TotalFilesProcessed *int64  `json:"total_files_processed"`
 	TotalBytesProcessed uint64  `json:"total_bytes_processed"`
 	TotalDuration       float64 `json:"total_duration"` // in seconds
 	SnapshotID          string  `json:"snapshot_id"`
-}
-
-type ForgetGroup struct {
-	Keep   []json.RawMessage `json:"keep"`
-	Remove []json.RawMessage `json:"remove"`
+	// Hostname is the host identity restic stamped onto the snapshot,
+	// i.e. whatever --host/RESTIC_HOST resolved to at backup time (restic
+	// 0.17+ includes it in the summary message).
+	Hostname string `json:"hostname,omitempty"`
+	// BackupStart and BackupEnd are restic 0.17+ only; they are nil on
+	// older versions that don't emit them.
+	BackupStart *time.Time `json:"backup_start,omitempty"`
+	BackupEnd   *time.Time `json:"backup_end,omitempty"`
+	// DryRun is true when the backup was run with --dry-run (restic 0.17+).
+	DryRun bool `json:"dry_run,omitempty"`
+	// SnapshotFileCount is the number of files tracked in the resulting
+	// snapshot (restic 0.17+).
+	SnapshotFileCount int64 `json:"snapshot_file_count,omitempty"`
 }
 
 type StatsContainer struct {
@@ -0,0 +1,98 @@
+/*
+Copyright The Stash Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restic
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtractBackupInfoFiresProgressCallback(t *testing.T) {
+	input := strings.Join([]string{
+		`{"message_type":"status","percent_done":0.1}`,
+		`{"message_type":"status","percent_done":0.6}`,
+		`{"message_type":"summary","snapshot_id":"abc123","data_added":100,"total_bytes_processed":1000}`,
+	}, "\n")
+
+	var seen []float64
+	out := &BackupOutput{
+		ProgressCallback: func(e StatusEvent) {
+			seen = append(seen, e.PercentDone)
+		},
+	}
+
+	if _, _, err := out.extractBackupInfo([]byte(input), "/data", ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(seen) != 2 || seen[0] != 0.1 || seen[1] != 0.6 {
+		t.Fatalf("expected ProgressCallback to fire once per StatusEvent with percent_done [0.1 0.6], got %v", seen)
+	}
+}
+
+func TestExtractBackupInfoPrefersPackedBytes(t *testing.T) {
+	input := `{"message_type":"summary","snapshot_id":"abc123","data_added":1000,"data_added_packed":400}`
+
+	out := &BackupOutput{}
+	stats, _, err := out.extractBackupInfo([]byte(input), "/data", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.Uploaded != formatBytes(400) {
+		t.Fatalf("expected Uploaded to use the post-compression data_added_packed figure, got %q", stats.Uploaded)
+	}
+}
+
+func TestExtractBackupInfoFallsBackToUnpackedBytes(t *testing.T) {
+	// restic versions older than 0.17 never emit data_added_packed at all.
+	input := `{"message_type":"summary","snapshot_id":"abc123","data_added":1000}`
+
+	out := &BackupOutput{}
+	stats, _, err := out.extractBackupInfo([]byte(input), "/data", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.Uploaded != formatBytes(1000) {
+		t.Fatalf("expected Uploaded to fall back to data_added when data_added_packed is absent, got %q", stats.Uploaded)
+	}
+}
+
+func TestExtractBackupInfoHostFallsBackToSummary(t *testing.T) {
+	input := `{"message_type":"summary","snapshot_id":"abc123","hostname":"from-summary"}`
+
+	out := &BackupOutput{}
+	_, host, err := out.extractBackupInfo([]byte(input), "/data", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if host != "from-summary" {
+		t.Fatalf("expected host to fall back to the summary's hostname, got %q", host)
+	}
+}
+
+func TestExtractBackupInfoHostPrefersExplicit(t *testing.T) {
+	input := `{"message_type":"summary","snapshot_id":"abc123","hostname":"from-summary"}`
+
+	out := &BackupOutput{}
+	_, host, err := out.extractBackupInfo([]byte(input), "/data", "explicit-host")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if host != "explicit-host" {
+		t.Fatalf("expected explicit host to win over the summary's hostname, got %q", host)
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Copyright The Stash Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restic
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+
+	api_v1beta1 "stash.appscode.dev/stash/apis/stash/v1beta1"
+)
+
+// Restic is a thin wrapper around the restic binary, parameterized with
+// enough information to run commands against one repository.
+type Restic struct {
+	// ResticBinary is the path to the restic executable.
+	ResticBinary string
+	// Env holds the environment variables restic needs to reach its
+	// repository, e.g. RESTIC_REPOSITORY, RESTIC_PASSWORD_FILE, and any
+	// cloud provider credentials, in addition to the process's own
+	// environment.
+	Env []string
+	// Host is the identity this Restic stamps onto backups via --host, and
+	// records on the resulting HostBackupStats entries. It's resolved once
+	// at construction time via ResolveHost, so every command this Restic
+	// runs agrees on the same host even if RESTIC_HOST changes later in
+	// the process's environment.
+	Host string
+}
+
+// NewRestic returns a Restic wrapper that invokes binary with env appended
+// to the current process's environment. host is resolved via ResolveHost:
+// an explicit value (e.g. a Stash CRD field) wins, otherwise RESTIC_HOST is
+// honored, and only then does it fall back to the pod's own os.Hostname().
+func NewRestic(binary string, env []string, host string) (*Restic, error) {
+	resolvedHost, err := ResolveHost(host)
+	if err != nil {
+		return nil, err
+	}
+	return &Restic{ResticBinary: binary, Env: env, Host: resolvedHost}, nil
+}
+
+// CheckWithReadData runs "restic check" with the read-data argument that
+// verifies percent of repository data (100 requests a full --read-data
+// pass; anything less requests the matching --read-data-subset=N%), and
+// parses its output into an IntegrityReport. This lets Stash policies
+// schedule e.g. weekly 10% deep checks while still reporting through the
+// same BackupOutput.RepositoryStats pipeline as a plain "restic check".
+//
+// restic check exits non-zero when it finds errors, which is a normal,
+// reportable result here rather than a failure to run the command at all,
+// so that exit status is folded into IntegrityReport.Verified instead of
+// being returned as an error.
+func (r *Restic) CheckWithReadData(percent int) (*IntegrityReport, error) {
+	cmd := exec.Command(r.ResticBinary, "check", readDataArg(percent))
+	cmd.Env = append(os.Environ(), r.Env...)
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	runErr := cmd.Run()
+
+	report := extractCheckInfo(out.Bytes())
+	if runErr != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(runErr, &exitErr) {
+			return nil, runErr
+		}
+		report.Verified = false
+	}
+	return report, nil
+}
+
+// Backup runs "restic backup --json path" stamped with r.Host, streaming
+// progress through out.ProgressCallback, and returns the resulting
+// SnapshotStats; it does not add them to out.HostBackupStats. It also
+// returns the host restic actually used, which is r.Host whenever that was
+// resolved to a non-empty value.
+func (r *Restic) Backup(out *BackupOutput, path string) (api_v1beta1.SnapshotStats, string, error) {
+	args := []string{"backup", "--json"}
+	if r.Host != "" {
+		args = append(args, "--host", r.Host)
+	}
+	args = append(args, path)
+
+	cmd := exec.Command(r.ResticBinary, args...)
+	cmd.Env = append(os.Environ(), r.Env...)
+
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return api_v1beta1.SnapshotStats{}, r.Host, err
+	}
+
+	return out.extractBackupInfo(stdout.Bytes(), path, r.Host)
+}
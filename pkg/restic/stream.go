@@ -0,0 +1,160 @@
+/*
+Copyright The Stash Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restic
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+)
+
+// restic stamps every line of "restic backup --json" output with one of
+// these message_type values.
+const (
+	MessageTypeStatus        = "status"
+	MessageTypeError         = "error"
+	MessageTypeVerboseStatus = "verbose_status"
+	MessageTypeSummary       = "summary"
+)
+
+// Event is a single decoded line of "restic backup --json" output,
+// classified by its message_type field.
+type Event interface {
+	isEvent()
+}
+
+// StatusEvent is restic's periodic progress update, emitted roughly every
+// few hundred milliseconds while a backup is running.
+type StatusEvent struct {
+	PercentDone      float64  `json:"percent_done"`
+	TotalFiles       int64    `json:"total_files"`
+	FilesDone        int64    `json:"files_done"`
+	TotalBytes       uint64   `json:"total_bytes"`
+	BytesDone        uint64   `json:"bytes_done"`
+	CurrentFiles     []string `json:"current_files"`
+	SecondsElapsed   int64    `json:"seconds_elapsed"`
+	SecondsRemaining int64    `json:"seconds_remaining"`
+}
+
+func (StatusEvent) isEvent() {}
+
+// ErrorEvent reports an error restic encountered while processing an item.
+type ErrorEvent struct {
+	Error  string `json:"error"`
+	During string `json:"during"`
+	Item   string `json:"item"`
+}
+
+func (ErrorEvent) isEvent() {}
+
+// VerboseStatusEvent reports a per-file action (new/changed/unmodified),
+// emitted when restic is run with --verbose.
+type VerboseStatusEvent struct {
+	Action string `json:"action"`
+	Item   string `json:"item"`
+	Size   uint64 `json:"size"`
+}
+
+func (VerboseStatusEvent) isEvent() {}
+
+// SummaryEvent is the final message restic emits once a backup completes.
+type SummaryEvent struct {
+	BackupSummary
+}
+
+func (SummaryEvent) isEvent() {}
+
+// BackupStreamParser decodes the line-delimited JSON stream produced by
+// "restic backup --json" and classifies each message by its message_type
+// field, so callers can react to progress as it happens instead of waiting
+// for the backup to finish.
+//
+// It scans line-by-line rather than handing the whole reader to a single
+// json.Decoder: a json.Decoder that fails mid-token on a malformed line
+// gets stuck returning that same error on every subsequent Decode call, so
+// there would be no way to skip past the bad line and keep reading. A
+// bufio.Scanner lets a single corrupted line be discarded on its own.
+type BackupStreamParser struct {
+	scanner *bufio.Scanner
+}
+
+// maxEventLineSize bounds how long a single line of restic's --json output
+// is allowed to be. restic's status events list every in-flight file under
+// current_files, which on a backup of a large tree can easily exceed
+// bufio.Scanner's default 64KB token limit; without raising it, a long
+// line would abort the scan with "token too long" instead of being decoded
+// (or even skipped as malformed).
+const maxEventLineSize = 10 * 1024 * 1024
+
+// NewBackupStreamParser returns a parser that reads restic's --json backup
+// output from r.
+func NewBackupStreamParser(r io.Reader) *BackupStreamParser {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEventLineSize)
+	return &BackupStreamParser{scanner: scanner}
+}
+
+// Next decodes and returns the next event from the stream. It returns
+// io.EOF once the stream is exhausted. A malformed or unrecognized line is
+// skipped rather than aborting the scan, so a single corrupted message
+// doesn't cost the caller every event that follows it.
+func (p *BackupStreamParser) Next() (Event, error) {
+	for p.scanner.Scan() {
+		line := p.scanner.Bytes()
+
+		var head struct {
+			MessageType string `json:"message_type"`
+		}
+		if err := json.Unmarshal(line, &head); err != nil {
+			continue
+		}
+
+		switch head.MessageType {
+		case MessageTypeStatus:
+			var e StatusEvent
+			if err := json.Unmarshal(line, &e); err != nil {
+				continue
+			}
+			return e, nil
+		case MessageTypeError:
+			var e ErrorEvent
+			if err := json.Unmarshal(line, &e); err != nil {
+				continue
+			}
+			return e, nil
+		case MessageTypeVerboseStatus:
+			var e VerboseStatusEvent
+			if err := json.Unmarshal(line, &e); err != nil {
+				continue
+			}
+			return e, nil
+		case MessageTypeSummary:
+			var e SummaryEvent
+			if err := json.Unmarshal(line, &e.BackupSummary); err != nil {
+				continue
+			}
+			return e, nil
+		default:
+			continue
+		}
+	}
+
+	if err := p.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
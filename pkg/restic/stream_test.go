@@ -0,0 +1,106 @@
+/*
+Copyright The Stash Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restic
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestBackupStreamParserSkipsMalformedLines(t *testing.T) {
+	input := strings.Join([]string{
+		`{"message_type":"status","percent_done":0.5}`,
+		`not even close to json`,
+		`{"message_type":"summary","snapshot_id":"abc123"}`,
+	}, "\n")
+
+	parser := NewBackupStreamParser(strings.NewReader(input))
+
+	event, err := parser.Next()
+	if err != nil {
+		t.Fatalf("unexpected error reading status event: %v", err)
+	}
+	status, ok := event.(StatusEvent)
+	if !ok || status.PercentDone != 0.5 {
+		t.Fatalf("expected StatusEvent{PercentDone: 0.5}, got %#v", event)
+	}
+
+	event, err = parser.Next()
+	if err != nil {
+		t.Fatalf("malformed line should be skipped, not returned as an error: %v", err)
+	}
+	summary, ok := event.(SummaryEvent)
+	if !ok || summary.SnapshotID != "abc123" {
+		t.Fatalf("expected SummaryEvent{SnapshotID: \"abc123\"} after the malformed line, got %#v", event)
+	}
+
+	if _, err := parser.Next(); err != io.EOF {
+		t.Fatalf("expected io.EOF at end of stream, got %v", err)
+	}
+}
+
+func TestBackupStreamParserHandlesOversizedLine(t *testing.T) {
+	// current_files can list many in-flight paths and routinely exceeds
+	// bufio.Scanner's default 64KB token limit on a large tree.
+	longFiles := make([]string, 2000)
+	for i := range longFiles {
+		longFiles[i] = strings.Repeat("a", 100)
+	}
+	fixture := struct {
+		MessageType string `json:"message_type"`
+		StatusEvent
+	}{
+		MessageType: MessageTypeStatus,
+		StatusEvent: StatusEvent{
+			PercentDone:  0.25,
+			CurrentFiles: longFiles,
+		},
+	}
+	statusLine, err := json.Marshal(fixture)
+	if err != nil {
+		t.Fatalf("failed to build fixture: %v", err)
+	}
+	if len(statusLine) < 64*1024 {
+		t.Fatalf("fixture line is only %d bytes, want > 64KB", len(statusLine))
+	}
+
+	input := strings.Join([]string{
+		string(statusLine),
+		`{"message_type":"summary","snapshot_id":"abc123"}`,
+	}, "\n")
+
+	parser := NewBackupStreamParser(strings.NewReader(input))
+
+	event, err := parser.Next()
+	if err != nil {
+		t.Fatalf("oversized status line should still be decoded, got error: %v", err)
+	}
+	status, ok := event.(StatusEvent)
+	if !ok || status.PercentDone != 0.25 || len(status.CurrentFiles) != len(longFiles) {
+		t.Fatalf("unexpected status event: %#v", event)
+	}
+
+	event, err = parser.Next()
+	if err != nil {
+		t.Fatalf("summary event after an oversized line should still be reached: %v", err)
+	}
+	if summary, ok := event.(SummaryEvent); !ok || summary.SnapshotID != "abc123" {
+		t.Fatalf("expected SummaryEvent{SnapshotID: \"abc123\"}, got %#v", event)
+	}
+}